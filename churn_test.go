@@ -0,0 +1,48 @@
+package shardmap_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/hnakamur/shardmap"
+)
+
+// TestMapStoreDeleteChurn exercises the tombstone, grow and compaction paths: it
+// repeatedly inserts a batch of keys, deletes every other one, and inserts
+// a fresh batch, which forces shardTable through several rounds of in-place
+// inserts, in-place tombstoning, and rebuilds.
+func TestMapStoreDeleteChurn(t *testing.T) {
+	var m shardmap.Map
+	want := make(map[string]int)
+
+	for round := 0; round < 20; round++ {
+		for i := 0; i < 500; i++ {
+			key := strconv.Itoa(round*500 + i)
+			m.Store(key, i)
+			want[key] = i
+		}
+		for i := 0; i < 500; i += 2 {
+			key := strconv.Itoa(round*500 + i)
+			m.Delete(key)
+			delete(want, key)
+		}
+	}
+
+	if n := m.Len(); n != len(want) {
+		t.Fatalf("Len() = %d, want %d", n, len(want))
+	}
+	for key, v := range want {
+		got, ok := m.Load(key)
+		if !ok || got != v {
+			t.Fatalf("Load(%q) = (%v, %v), want (%v, true)", key, got, ok, v)
+		}
+	}
+	m.Range(func(key, value interface{}) bool {
+		k := key.(string)
+		v, ok := want[k]
+		if !ok || v != value {
+			t.Fatalf("Range visited stale entry %q=%v", k, value)
+		}
+		return true
+	})
+}
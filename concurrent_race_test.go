@@ -0,0 +1,45 @@
+package shardmap_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/hnakamur/shardmap"
+)
+
+// TestMapConcurrentStoreDeleteLoad exercises Store, Delete and Load
+// concurrently from independent goroutines over a small keyspace, so the
+// lock-free read path's linear probe in find walks through slots that other
+// goroutines are concurrently inserting into and tombstoning. Run with
+// -race: it only catches an unsynchronized access to a shardSlot field, not
+// any particular outcome, so it makes no assertion about the values Load
+// returns.
+func TestMapConcurrentStoreDeleteLoad(t *testing.T) {
+	var m shardmap.Map
+	const keys = 32
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = "k" + strconv.Itoa(i)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := names[(g+i)%keys]
+				switch i % 3 {
+				case 0:
+					m.Store(key, i)
+				case 1:
+					m.Delete(key)
+				default:
+					m.Load(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
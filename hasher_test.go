@@ -0,0 +1,82 @@
+package shardmap_test
+
+import (
+	"testing"
+
+	"github.com/hnakamur/shardmap"
+)
+
+type point struct{ x, y int }
+
+func fnvHash(key interface{}) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	switch k := key.(type) {
+	case string:
+		for i := 0; i < len(k); i++ {
+			h ^= uint64(k[i])
+			h *= prime64
+		}
+	case point:
+		h ^= uint64(k.x)
+		h *= prime64
+		h ^= uint64(k.y)
+		h *= prime64
+	default:
+		panic("fnvHash: unsupported key type")
+	}
+	return h
+}
+
+func TestMapWithHasherIsStableAcrossInstances(t *testing.T) {
+	m1 := shardmap.New(0, shardmap.WithHasher(fnvHash))
+	m2 := shardmap.New(0, shardmap.WithHasher(fnvHash))
+	for i := 0; i < 100; i++ {
+		key := point{i, -i}
+		m1.Store(key, i)
+		m2.Store(key, i)
+	}
+	// Range visits shards, and slots within a shard, in index order, so the
+	// two maps visit keys in the same order only if both placed every key
+	// in the same shard and slot.
+	var keys1, keys2 []interface{}
+	m1.Range(func(k, v interface{}) bool { keys1 = append(keys1, k); return true })
+	m2.Range(func(k, v interface{}) bool { keys2 = append(keys2, k); return true })
+	if len(keys1) != len(keys2) {
+		t.Fatalf("got %d entries in m1, %d in m2", len(keys1), len(keys2))
+	}
+	for i := range keys1 {
+		if keys1[i] != keys2[i] {
+			t.Fatalf("Range order differs at %d: m1 visited %v, m2 visited %v", i, keys1[i], keys2[i])
+		}
+	}
+}
+
+func TestMapWithHasherAllowsStructKeys(t *testing.T) {
+	m := shardmap.New(0, shardmap.WithHasher(fnvHash))
+	m.Store(point{1, 2}, "a")
+	v, ok := m.Load(point{1, 2})
+	if !ok || v != "a" {
+		t.Fatalf("Load: got (%v, %v), want (a, true)", v, ok)
+	}
+	if _, ok := m.Load(point{3, 4}); ok {
+		t.Fatalf("Load of missing struct key returned ok=true")
+	}
+}
+
+// Without a HasherFunc, Map falls back to reflect-based hashing for struct
+// keys, so this no longer needs WithHasher.
+func TestMapWithoutHasherAllowsStructKeys(t *testing.T) {
+	var m shardmap.Map
+	m.Store(point{1, 2}, "a")
+	v, ok := m.Load(point{1, 2})
+	if !ok || v != "a" {
+		t.Fatalf("Load: got (%v, %v), want (a, true)", v, ok)
+	}
+	if _, ok := m.Load(point{3, 4}); ok {
+		t.Fatalf("Load of missing struct key returned ok=true")
+	}
+}
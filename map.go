@@ -3,44 +3,275 @@ package shardmap
 import (
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 )
 
+const (
+	minTableSlots     = 8
+	tableGrowFactor   = 0.75
+	tableShrinkFactor = 0.10
+)
+
 // Map is a hashmap. Like map[string]interface{}, but sharded and thread-safe.
+//
+// Each shard publishes its contents as a *shardTable behind an atomic
+// pointer. Load (and the negative-lookup path of LoadOrStore) reads that
+// pointer and probes the table without ever taking mus[shard], so reads
+// never block on, or are blocked by, a concurrent writer. Writers still
+// serialize on mus[shard], but most writes are amortized O(1): updating an
+// existing key publishes just its value with an atomic store, and adding
+// or removing a key publishes just that one slot the same way, as long as
+// the shard's table has room. The table is only rebuilt - and republished
+// with a single atomic store of a new pointer - when it must grow or when
+// deletes have left it needing compaction; see shardTable.
 type Map struct {
 	init   sync.Once
 	cap    int
 	shards int
 	seed   uint32
+	hasher HasherFunc
 	mus    []sync.RWMutex
-	maps   []map[interface{}]interface{}
+	tables []unsafe.Pointer // *shardTable, read/written via atomic
+}
+
+// HasherFunc computes the hash used to assign a key to a shard and to probe
+// within it. It receives the key exactly as it was passed to Store, Load,
+// and the other Map methods.
+//
+// Map's default hashing (see choose) goes through Go's per-process aeshash
+// seed via runtime.memhash, which differs across process restarts and so
+// cannot be used as a persistent hash: it is unsuitable for anything that
+// needs shard assignment to be reproducible, such as sticky routing,
+// hash-partitioned on-disk spill, or cross-node coordination. Pass a
+// HasherFunc to WithHasher for a stable alternative, such as xxhash or fnv.
+//
+// A HasherFunc must be deterministic: equal keys must produce equal hashes.
+type HasherFunc func(key interface{}) uint64
+
+// Option configures a Map constructed with New.
+type Option func(*Map)
+
+// WithHasher sets the HasherFunc a Map uses in place of its built-in
+// hashing.
+func WithHasher(h HasherFunc) Option {
+	return func(m *Map) {
+		m.hasher = h
+	}
+}
+
+// shardSlot is one open-addressed slot in a shardTable. entry is nil for an
+// empty slot and tombstonePtr for a slot whose key has been deleted;
+// otherwise it points at a boxedEntry holding the hash and key together, so
+// the pair can be published - or unpublished by a delete - with a single
+// atomic store of entry. value points at a boxed interface{} and may change
+// in place, underneath a reader, via a further atomic store of value alone.
+type shardSlot struct {
+	entry unsafe.Pointer // *boxedEntry, nil, or tombstonePtr
+	value unsafe.Pointer // *interface{}
+}
+
+// boxedEntry pairs a key with the hash it was inserted under, so find can
+// compare both without a separate, independently-published field: once a
+// shardSlot's entry pointer is visible to a reader, the boxedEntry it
+// points to never changes.
+type boxedEntry struct {
+	hash uint64
+	key  interface{}
+}
+
+// tombstonePtr marks a slot whose key was deleted. Using a sentinel instead
+// of resetting the slot to nil lets find keep probing past it for a key
+// that collided with it, the same way a live slot would. A tombstone is
+// never reused for a new key in place: a reader that loaded the deleted
+// key's entry may still go on to read the slot's value, so the slot stays a
+// tombstone until a rebuild compacts the table.
+var tombstonePtr = unsafe.Pointer(new(interface{}))
+
+// notFoundIdx is the skipIdx value meaning "don't skip any slot" in
+// rebuild.
+const notFoundIdx = ^uint64(0)
+
+// shardTable is an open-addressed hash table. Inserting a new key or
+// deleting one publishes that single slot with an atomic store of its
+// entry pointer (last, after its value is set), so readers that loaded the
+// table pointer before the write either see the slot as it was or see it
+// fully formed, never half-written. The table itself is only replaced
+// wholesale - rebuilt and published via a new atomic pointer store to
+// Map.tables[shard] - when it must grow or when accumulated tombstones
+// need to be compacted away; ordinary inserts and deletes are O(1).
+type shardTable struct {
+	slots    []shardSlot
+	mask     uint64
+	live     int // slots holding a live key
+	occupied int // live slots plus tombstones; bounds probe length
+}
+
+func newShardTable(hint int) *shardTable {
+	n := minTableSlots
+	for n < hint {
+		n *= 2
+	}
+	return &shardTable{slots: make([]shardSlot, n), mask: uint64(n - 1)}
+}
+
+// find returns the slot index for hash/key, probing linearly from its ideal
+// slot and continuing past tombstones rather than stopping at them. found
+// is true if the key is already present, in which case idx is its slot;
+// otherwise idx is the empty slot that ended the probe, which the key can be
+// inserted into (tombstones are not reused; see tombstonePtr).
+func (t *shardTable) find(hash uint64, key interface{}) (idx uint64, found bool) {
+	idx = hash & t.mask
+	for {
+		ep := atomic.LoadPointer(&t.slots[idx].entry)
+		switch {
+		case ep == nil:
+			return idx, false
+		case ep == tombstonePtr:
+		default:
+			e := (*boxedEntry)(ep)
+			if e.hash == hash && e.key == key {
+				return idx, true
+			}
+		}
+		idx = (idx + 1) & t.mask
+	}
+}
+
+// insertSlot publishes key/value at idx in t, the caller having already
+// established via find that idx is empty. The entry pointer
+// is stored last so that a reader who observes it has an already-visible
+// value field to read.
+func insertSlot(t *shardTable, idx uint64, hash uint64, key, value interface{}) {
+	wasEmpty := atomic.LoadPointer(&t.slots[idx].entry) == nil
+	atomic.StorePointer(&t.slots[idx].value, boxInterface(value))
+	atomic.StorePointer(&t.slots[idx].entry, boxEntry(hash, key))
+	if wasEmpty {
+		t.occupied++
+	}
+}
+
+// rebuild returns a new, tombstone-free table of the given size containing
+// every live slot of t except skipIdx (pass notFoundIdx to skip none). It
+// is used to publish a new shardTable when a write must grow the table or
+// compact away its tombstones.
+func rebuild(t *shardTable, size int, skipIdx uint64) *shardTable {
+	nt := newShardTable(size)
+	if t == nil {
+		return nt
+	}
+	for i := range t.slots {
+		if uint64(i) == skipIdx {
+			continue
+		}
+		ep := atomic.LoadPointer(&t.slots[i].entry)
+		if ep == nil || ep == tombstonePtr {
+			continue
+		}
+		e := (*boxedEntry)(ep)
+		idx, _ := nt.find(e.hash, e.key)
+		nt.slots[idx] = shardSlot{entry: ep, value: atomic.LoadPointer(&t.slots[i].value)}
+		nt.live++
+		nt.occupied++
+	}
+	return nt
+}
+
+func boxInterface(v interface{}) unsafe.Pointer {
+	return unsafe.Pointer(&v)
+}
+
+// boxEntry pairs hash and key into a single boxedEntry that a shardSlot's
+// entry field can publish atomically.
+func boxEntry(hash uint64, key interface{}) unsafe.Pointer {
+	return unsafe.Pointer(&boxedEntry{hash: hash, key: key})
 }
 
-// New returns a new hashmap with the specified capacity. This function is only
-// needed when you must define a minimum capacity, otherwise just use:
+// New returns a new hashmap with the specified capacity. This function is
+// only needed when you must define a minimum capacity or pass options such
+// as WithHasher, otherwise just use:
 //    var m Map
-func New(cap int) *Map {
-	return &Map{cap: cap}
+func New(cap int, opts ...Option) *Map {
+	m := &Map{cap: cap}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
 // Store sets the value for a key.
 func (m *Map) Store(key, value interface{}) {
 	m.initDo()
-	shard := m.choose(key)
+	shard, hash := m.choose(key)
 	m.mus[shard].Lock()
-	m.maps[shard][key] = value
+	m.storeLocked(shard, hash, key, value)
 	m.mus[shard].Unlock()
 }
 
+// storeLocked inserts or updates key/value in shard. The caller must hold
+// mus[shard].
+//
+// An update of an existing key publishes just its value, in place, with a
+// single atomic store. A new key is inserted in place too, as long as the
+// table has room; only once occupied slots (live keys plus tombstones)
+// would cross tableGrowFactor does this rebuild and publish a new table,
+// which keeps ordinary Store calls amortized O(1) instead of paying an
+// O(shard size) rebuild on every call.
+func (m *Map) storeLocked(shard int, hash uint64, key, value interface{}) {
+	old := m.loadTable(shard)
+	if old != nil {
+		idx, found := old.find(hash, key)
+		if found {
+			atomic.StorePointer(&old.slots[idx].value, boxInterface(value))
+			return
+		}
+		if float64(old.occupied+1) <= tableGrowFactor*float64(len(old.slots)) {
+			insertSlot(old, idx, hash, key, value)
+			old.live++
+			return
+		}
+	}
+	nt := rebuildForGrow(old)
+	idx, _ := nt.find(hash, key)
+	insertSlot(nt, idx, hash, key, value)
+	nt.live++
+	m.storeTable(shard, nt)
+}
+
+// rebuildForGrow compacts old's tombstones away, growing its size only if
+// old's live keys (plus the one about to be inserted) would not fit back
+// into the same size once those tombstones are gone. This keeps a
+// store/delete churn pattern from growing the table forever.
+func rebuildForGrow(old *shardTable) *shardTable {
+	size := minTableSlots
+	if old != nil {
+		size = len(old.slots)
+		if float64(old.live+1) > tableGrowFactor*float64(size) {
+			size *= 2
+		}
+	}
+	return rebuild(old, size, notFoundIdx)
+}
+
 // Load returns the value stored in the map for a key, or nil if no value is present.
 // The ok result indicates whether value was found in the map.
 func (m *Map) Load(key interface{}) (value interface{}, ok bool) {
 	m.initDo()
-	shard := m.choose(key)
-	m.mus[shard].RLock()
-	value, ok = m.maps[shard][key]
-	m.mus[shard].RUnlock()
-	return value, ok
+	shard, hash := m.choose(key)
+	return m.loadLocklessly(shard, hash, key)
+}
+
+// loadLocklessly probes shard's current table without taking mus[shard].
+func (m *Map) loadLocklessly(shard int, hash uint64, key interface{}) (value interface{}, ok bool) {
+	t := m.loadTable(shard)
+	if t == nil {
+		return nil, false
+	}
+	idx, found := t.find(hash, key)
+	if !found {
+		return nil, false
+	}
+	return *(*interface{})(atomic.LoadPointer(&t.slots[idx].value)), true
 }
 
 // LoadOrStore returns the existing value for the key if present.
@@ -48,26 +279,113 @@ func (m *Map) Load(key interface{}) (value interface{}, ok bool) {
 // is true if the value was loaded, false if stored.
 func (m *Map) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
 	m.initDo()
-	shard := m.choose(key)
+	shard, hash := m.choose(key)
+	if actual, loaded = m.loadLocklessly(shard, hash, key); loaded {
+		return actual, true
+	}
 	m.mus[shard].Lock()
 	defer m.mus[shard].Unlock()
-	actual, loaded = m.maps[shard][key]
-	if loaded {
+	if actual, loaded = m.loadLocklessly(shard, hash, key); loaded {
 		return actual, true
 	}
-	m.maps[shard][key] = value
+	m.storeLocked(shard, hash, key, value)
 	return value, false
 }
 
 // Delete deletes the value for a key.
 func (m *Map) Delete(key interface{}) {
 	m.initDo()
-	shard := m.choose(key)
+	shard, hash := m.choose(key)
 	m.mus[shard].Lock()
-	delete(m.maps[shard], key)
+	m.deleteLocked(shard, hash, key)
 	m.mus[shard].Unlock()
 }
 
+// LoadAndDelete deletes the value for a key, returning the previous value
+// if any. The loaded result reports whether the key was present.
+func (m *Map) LoadAndDelete(key interface{}) (value interface{}, loaded bool) {
+	m.initDo()
+	shard, hash := m.choose(key)
+	m.mus[shard].Lock()
+	defer m.mus[shard].Unlock()
+	return m.deleteLocked(shard, hash, key)
+}
+
+// Swap stores value for key and returns the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *Map) Swap(key, value interface{}) (previous interface{}, loaded bool) {
+	m.initDo()
+	shard, hash := m.choose(key)
+	m.mus[shard].Lock()
+	defer m.mus[shard].Unlock()
+	previous, loaded = m.loadLocklessly(shard, hash, key)
+	m.storeLocked(shard, hash, key, value)
+	return previous, loaded
+}
+
+// CompareAndSwap swaps the old and new values for key if the value stored
+// in the map is equal to old. The old value must be of a comparable type.
+func (m *Map) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	m.initDo()
+	shard, hash := m.choose(key)
+	m.mus[shard].Lock()
+	defer m.mus[shard].Unlock()
+	actual, loaded := m.loadLocklessly(shard, hash, key)
+	if !loaded || actual != old {
+		return false
+	}
+	m.storeLocked(shard, hash, key, new)
+	return true
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+// The old value must be of a comparable type.
+//
+// If there is no current value for key in the map, CompareAndDelete
+// returns false (even if the old value is the nil interface value).
+func (m *Map) CompareAndDelete(key, old interface{}) (deleted bool) {
+	m.initDo()
+	shard, hash := m.choose(key)
+	m.mus[shard].Lock()
+	defer m.mus[shard].Unlock()
+	actual, loaded := m.loadLocklessly(shard, hash, key)
+	if !loaded || actual != old {
+		return false
+	}
+	m.deleteLocked(shard, hash, key)
+	return true
+}
+
+// deleteLocked removes key from shard, if present. The caller must hold
+// mus[shard].
+func (m *Map) deleteLocked(shard int, hash uint64, key interface{}) (value interface{}, deleted bool) {
+	old := m.loadTable(shard)
+	if old == nil {
+		return nil, false
+	}
+	idx, found := old.find(hash, key)
+	if !found {
+		return nil, false
+	}
+	value = *(*interface{})(atomic.LoadPointer(&old.slots[idx].value))
+	newLive := old.live - 1
+	size := len(old.slots)
+	for size > minTableSlots && float64(newLive) < tableShrinkFactor*float64(size/2) {
+		size /= 2
+	}
+	if size != len(old.slots) {
+		nt := rebuild(old, size, idx)
+		m.storeTable(shard, nt)
+		return value, true
+	}
+	// No shrink due: leave the key's slot as a tombstone, published with a
+	// single atomic store, rather than rebuilding the whole table to remove
+	// one entry.
+	atomic.StorePointer(&old.slots[idx].entry, tombstonePtr)
+	old.live = newLive
+	return value, true
+}
+
 // Range calls f sequentially for each key and value present in the map.
 // If f returns false, range stops the iteration.
 //
@@ -78,71 +396,102 @@ func (m *Map) Delete(key interface{}) {
 //
 // Range may be O(N) with the number of elements in the map even if f returns
 // false after a constant number of calls.
+//
+// Range loads each shard's table pointer once, then iterates the slots it
+// found there, so at least the set of slots considered for a shard is
+// fixed for the rest of that shard's iteration; a concurrent Store or
+// Delete that has to grow or compact the table (see shardTable) won't be
+// seen at all, while one that only needs to touch an existing slot in
+// place may or may not be, per the non-snapshot guarantee above.
 func (m *Map) Range(iter func(key, value interface{}) bool) {
 	m.initDo()
-	var done bool
 	for i := 0; i < m.shards; i++ {
-		func() {
-			m.mus[i].RLock()
-			defer m.mus[i].RUnlock()
-			for key, value := range m.maps[i] {
-				if !iter(key, value) {
-					done = true
-					break
-				}
+		t := m.loadTable(i)
+		if t == nil {
+			continue
+		}
+		cont := true
+		for idx := range t.slots {
+			ep := atomic.LoadPointer(&t.slots[idx].entry)
+			if ep == nil || ep == tombstonePtr {
+				continue
 			}
-		}()
-		if done {
+			key := (*boxedEntry)(ep).key
+			value := *(*interface{})(atomic.LoadPointer(&t.slots[idx].value))
+			if !iter(key, value) {
+				cont = false
+				break
+			}
+		}
+		if !cont {
 			break
 		}
 	}
 }
 
-func (m *Map) choose(key interface{}) int {
-	var h uint64
+func (m *Map) loadTable(shard int) *shardTable {
+	return (*shardTable)(atomic.LoadPointer(&m.tables[shard]))
+}
+
+func (m *Map) storeTable(shard int, t *shardTable) {
+	atomic.StorePointer(&m.tables[shard], unsafe.Pointer(t))
+}
+
+func (m *Map) choose(key interface{}) (shard int, hash uint64) {
+	hash = m.hashKey(key)
+	return int(hash & uint64(m.shards-1)), hash
+}
+
+// hashKey returns the hash used to place key in a shard. It has a fast path
+// for a fixed set of primitive types, matching what the Go runtime does for
+// map[interface{}]T; any other comparable type falls through to reflectHash,
+// which hashes the key at runtime using its reflect.Type.
+func (m *Map) hashKey(key interface{}) uint64 {
+	if m.hasher != nil {
+		return m.hasher(key)
+	}
 	switch k := key.(type) {
 	case nil:
-		// do nothing
+		return 0
 	case bool:
-		h = memhashBool(k)
+		return memhashBool(k)
 	case int:
-		h = memhashInt(k)
+		return memhashInt(k)
 	case uint:
-		h = memhashUint(k)
+		return memhashUint(k)
 	case uintptr:
-		h = memhashUintptr(k)
+		return memhashUintptr(k)
 	case int8:
-		h = memhashInt8(k)
+		return memhashInt8(k)
 	case uint8:
-		h = memhashUint8(k)
+		return memhashUint8(k)
 	case int16:
-		h = memhashInt16(k)
+		return memhashInt16(k)
 	case uint16:
-		h = memhashUint16(k)
+		return memhashUint16(k)
 	case int32:
-		h = memhashInt32(k)
+		return memhashInt32(k)
 	case uint32:
-		h = memhashUint32(k)
+		return memhashUint32(k)
 	case int64:
-		h = memhashInt64(k)
+		return memhashInt64(k)
 	case uint64:
-		h = memhashUint64(k)
+		return memhashUint64(k)
 	case float32:
-		h = memhashFloat32(k)
+		return memhashFloat32(k)
 	case float64:
-		h = memhashFloat64(k)
+		return memhashFloat64(k)
 	case complex64:
-		h = memhashComplex64(k)
+		return memhashComplex64(k)
 	case complex128:
-		h = memhashComplex128(k)
+		return memhashComplex128(k)
 	case string:
-		h = memHashString(k)
+		return memHashString(k)
 	case []byte:
-		h = memHash(k)
+		return memHash(k)
 	default:
-		panic("unsupported key type in shardmap.Map")
+		return reflectHash(key)
 	}
-	return int(h & uint64(m.shards-1))
 }
 
 func (m *Map) initDo() {
@@ -153,9 +502,9 @@ func (m *Map) initDo() {
 		}
 		scap := m.cap / m.shards
 		m.mus = make([]sync.RWMutex, m.shards)
-		m.maps = make([]map[interface{}]interface{}, m.shards)
-		for i := 0; i < len(m.maps); i++ {
-			m.maps[i] = make(map[interface{}]interface{}, scap)
+		m.tables = make([]unsafe.Pointer, m.shards)
+		for i := 0; i < m.shards; i++ {
+			m.tables[i] = unsafe.Pointer(newShardTable(scap))
 		}
 	})
 }
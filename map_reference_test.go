@@ -16,7 +16,11 @@ type mapInterface interface {
 	Load(string) (interface{}, bool)
 	Store(key string, value interface{})
 	LoadOrStore(key string, value interface{}) (actual interface{}, loaded bool)
+	LoadAndDelete(key string) (value interface{}, loaded bool)
 	Delete(string)
+	Swap(key string, value interface{}) (previous interface{}, loaded bool)
+	CompareAndSwap(key string, old, new interface{}) (swapped bool)
+	CompareAndDelete(key string, old interface{}) (deleted bool)
 	Range(func(key string, value interface{}) (shouldContinue bool))
 }
 
@@ -56,12 +60,55 @@ func (m *RWMutexMap) LoadOrStore(key string, value interface{}) (actual interfac
 	return actual, loaded
 }
 
+func (m *RWMutexMap) LoadAndDelete(key string) (value interface{}, loaded bool) {
+	m.mu.Lock()
+	value, loaded = m.dirty[key]
+	if loaded {
+		delete(m.dirty, key)
+	}
+	m.mu.Unlock()
+	return value, loaded
+}
+
 func (m *RWMutexMap) Delete(key string) {
 	m.mu.Lock()
 	delete(m.dirty, key)
 	m.mu.Unlock()
 }
 
+func (m *RWMutexMap) Swap(key string, value interface{}) (previous interface{}, loaded bool) {
+	m.mu.Lock()
+	previous, loaded = m.dirty[key]
+	if m.dirty == nil {
+		m.dirty = make(map[string]interface{})
+	}
+	m.dirty[key] = value
+	m.mu.Unlock()
+	return previous, loaded
+}
+
+func (m *RWMutexMap) CompareAndSwap(key string, old, new interface{}) (swapped bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	actual, ok := m.dirty[key]
+	if !ok || actual != old {
+		return false
+	}
+	m.dirty[key] = new
+	return true
+}
+
+func (m *RWMutexMap) CompareAndDelete(key string, old interface{}) (deleted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	actual, ok := m.dirty[key]
+	if !ok || actual != old {
+		return false
+	}
+	delete(m.dirty, key)
+	return true
+}
+
 func (m *RWMutexMap) Range(f func(key string, value interface{}) (shouldContinue bool)) {
 	m.mu.RLock()
 	keys := make([]string, 0, len(m.dirty))
@@ -124,6 +171,18 @@ func (m *DeepCopyMap) LoadOrStore(key string, value interface{}) (actual interfa
 	return actual, loaded
 }
 
+func (m *DeepCopyMap) LoadAndDelete(key string) (value interface{}, loaded bool) {
+	m.mu.Lock()
+	dirty := m.dirty()
+	value, loaded = dirty[key]
+	if loaded {
+		delete(dirty, key)
+		m.clean.Store(dirty)
+	}
+	m.mu.Unlock()
+	return value, loaded
+}
+
 func (m *DeepCopyMap) Delete(key string) {
 	m.mu.Lock()
 	dirty := m.dirty()
@@ -132,6 +191,44 @@ func (m *DeepCopyMap) Delete(key string) {
 	m.mu.Unlock()
 }
 
+func (m *DeepCopyMap) Swap(key string, value interface{}) (previous interface{}, loaded bool) {
+	m.mu.Lock()
+	dirty := m.dirty()
+	previous, loaded = dirty[key]
+	dirty[key] = value
+	m.clean.Store(dirty)
+	m.mu.Unlock()
+	return previous, loaded
+}
+
+func (m *DeepCopyMap) CompareAndSwap(key string, old, new interface{}) (swapped bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clean, _ := m.clean.Load().(map[string]interface{})
+	actual, ok := clean[key]
+	if !ok || actual != old {
+		return false
+	}
+	dirty := m.dirty()
+	dirty[key] = new
+	m.clean.Store(dirty)
+	return true
+}
+
+func (m *DeepCopyMap) CompareAndDelete(key string, old interface{}) (deleted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clean, _ := m.clean.Load().(map[string]interface{})
+	actual, ok := clean[key]
+	if !ok || actual != old {
+		return false
+	}
+	dirty := m.dirty()
+	delete(dirty, key)
+	m.clean.Store(dirty)
+	return true
+}
+
 func (m *DeepCopyMap) Range(f func(key string, value interface{}) (shouldContinue bool)) {
 	clean, _ := m.clean.Load().(map[string]interface{})
 	for k, v := range clean {
@@ -0,0 +1,208 @@
+package shardmap_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/hnakamur/shardmap"
+)
+
+// shardMapAdapter adapts *shardmap.Map, whose methods take interface{}
+// keys, to the string-keyed mapInterface in map_reference_test.go, so the same
+// property-based tests can run against Map, RWMutexMap and DeepCopyMap.
+type shardMapAdapter struct {
+	m shardmap.Map
+}
+
+func (a *shardMapAdapter) Load(key string) (interface{}, bool) { return a.m.Load(key) }
+
+func (a *shardMapAdapter) Store(key string, value interface{}) { a.m.Store(key, value) }
+
+func (a *shardMapAdapter) LoadOrStore(key string, value interface{}) (interface{}, bool) {
+	return a.m.LoadOrStore(key, value)
+}
+
+func (a *shardMapAdapter) LoadAndDelete(key string) (interface{}, bool) {
+	return a.m.LoadAndDelete(key)
+}
+
+func (a *shardMapAdapter) Delete(key string) { a.m.Delete(key) }
+
+func (a *shardMapAdapter) Swap(key string, value interface{}) (interface{}, bool) {
+	return a.m.Swap(key, value)
+}
+
+func (a *shardMapAdapter) CompareAndSwap(key string, old, new interface{}) bool {
+	return a.m.CompareAndSwap(key, old, new)
+}
+
+func (a *shardMapAdapter) CompareAndDelete(key string, old interface{}) bool {
+	return a.m.CompareAndDelete(key, old)
+}
+
+func (a *shardMapAdapter) Range(f func(key string, value interface{}) bool) {
+	a.m.Range(func(key, value interface{}) bool { return f(key.(string), value) })
+}
+
+// mapOp is one call to exercise against a mapInterface.
+type mapOp string
+
+const (
+	opLoad             = mapOp("Load")
+	opStore            = mapOp("Store")
+	opLoadOrStore      = mapOp("LoadOrStore")
+	opLoadAndDelete    = mapOp("LoadAndDelete")
+	opDelete           = mapOp("Delete")
+	opSwap             = mapOp("Swap")
+	opCompareAndSwap   = mapOp("CompareAndSwap")
+	opCompareAndDelete = mapOp("CompareAndDelete")
+)
+
+var mapOps = [...]mapOp{
+	opLoad, opStore, opLoadOrStore, opLoadAndDelete, opDelete,
+	opSwap, opCompareAndSwap, opCompareAndDelete,
+}
+
+// mapCall is a quick.Generator for a single (op, key, value) call against a
+// mapInterface, returning its result as a comparable summary so that two
+// implementations fed the same sequence of calls can be compared.
+type mapCall struct {
+	op mapOp
+	k  string
+	v  interface{}
+}
+
+func (c mapCall) apply(m mapInterface) (interface{}, bool) {
+	switch c.op {
+	case opLoad:
+		return m.Load(c.k)
+	case opStore:
+		m.Store(c.k, c.v)
+		return nil, false
+	case opLoadOrStore:
+		return m.LoadOrStore(c.k, c.v)
+	case opLoadAndDelete:
+		return m.LoadAndDelete(c.k)
+	case opDelete:
+		m.Delete(c.k)
+		return nil, false
+	case opSwap:
+		return m.Swap(c.k, c.v)
+	case opCompareAndSwap:
+		return nil, m.CompareAndSwap(c.k, c.v, "swapped")
+	case opCompareAndDelete:
+		return nil, m.CompareAndDelete(c.k, c.v)
+	default:
+		panic("invalid mapOp: " + string(c.op))
+	}
+}
+
+func (mapCall) Generate(r *rand.Rand, size int) reflect.Value {
+	c := mapCall{op: mapOps[rand.Intn(len(mapOps))], k: string(rune('a' + rand.Intn(3)))}
+	if rand.Intn(2) == 0 {
+		c.v = rand.Intn(100)
+	} else {
+		c.v = nil
+	}
+	return reflect.ValueOf(c)
+}
+
+func applyCalls(m mapInterface, calls []mapCall) (results []interface{}, final map[string]interface{}) {
+	for _, c := range calls {
+		v, ok := c.apply(m)
+		results = append(results, v, ok)
+	}
+	final = make(map[string]interface{})
+	m.Range(func(k string, v interface{}) bool {
+		final[k] = v
+		return true
+	})
+	return results, final
+}
+
+func applyMap(calls []mapCall) ([]interface{}, map[string]interface{}) {
+	return applyCalls(&shardMapAdapter{}, calls)
+}
+
+func applyRWMutexMap(calls []mapCall) ([]interface{}, map[string]interface{}) {
+	return applyCalls(new(RWMutexMap), calls)
+}
+
+func applyDeepCopyMap(calls []mapCall) ([]interface{}, map[string]interface{}) {
+	return applyCalls(new(DeepCopyMap), calls)
+}
+
+func TestMapMatchesRWMutexMap(t *testing.T) {
+	if err := quick.CheckEqual(applyMap, applyRWMutexMap, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMapMatchesDeepCopyMap(t *testing.T) {
+	if err := quick.CheckEqual(applyMap, applyDeepCopyMap, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMapLoadAndDelete(t *testing.T) {
+	var m shardmap.Map
+	m.Store("k", 1)
+	v, loaded := m.LoadAndDelete("k")
+	if !loaded || v != 1 {
+		t.Fatalf("LoadAndDelete: got (%v, %v), want (1, true)", v, loaded)
+	}
+	if _, ok := m.Load("k"); ok {
+		t.Fatalf("Load after LoadAndDelete returned ok=true")
+	}
+	if _, loaded := m.LoadAndDelete("k"); loaded {
+		t.Fatalf("LoadAndDelete of missing key returned loaded=true")
+	}
+}
+
+func TestMapSwap(t *testing.T) {
+	var m shardmap.Map
+	previous, loaded := m.Swap("k", 1)
+	if loaded || previous != nil {
+		t.Fatalf("first Swap: got (%v, %v), want (nil, false)", previous, loaded)
+	}
+	previous, loaded = m.Swap("k", 2)
+	if !loaded || previous != 1 {
+		t.Fatalf("second Swap: got (%v, %v), want (1, true)", previous, loaded)
+	}
+	if v, _ := m.Load("k"); v != 2 {
+		t.Fatalf("Load after Swap: got %v, want 2", v)
+	}
+}
+
+func TestMapCompareAndSwap(t *testing.T) {
+	var m shardmap.Map
+	if m.CompareAndSwap("k", 1, 2) {
+		t.Fatalf("CompareAndSwap on missing key returned true")
+	}
+	m.Store("k", 1)
+	if m.CompareAndSwap("k", 2, 3) {
+		t.Fatalf("CompareAndSwap with wrong old value returned true")
+	}
+	if !m.CompareAndSwap("k", 1, 3) {
+		t.Fatalf("CompareAndSwap with correct old value returned false")
+	}
+	if v, _ := m.Load("k"); v != 3 {
+		t.Fatalf("Load after CompareAndSwap: got %v, want 3", v)
+	}
+}
+
+func TestMapCompareAndDelete(t *testing.T) {
+	var m shardmap.Map
+	m.Store("k", 1)
+	if m.CompareAndDelete("k", 2) {
+		t.Fatalf("CompareAndDelete with wrong old value returned true")
+	}
+	if !m.CompareAndDelete("k", 1) {
+		t.Fatalf("CompareAndDelete with correct old value returned false")
+	}
+	if _, ok := m.Load("k"); ok {
+		t.Fatalf("Load after CompareAndDelete returned ok=true")
+	}
+}
@@ -0,0 +1,97 @@
+package shardmap
+
+import (
+	"reflect"
+	"sync"
+)
+
+// reflectHashers caches, per reflect.Type, a function that hashes a key of
+// that type. It is consulted only for key types hashKey's switch doesn't
+// know natively (anything beyond the primitives and []byte/string), so the
+// hash of the type's name is computed once per type; reflect.TypeOf and
+// reflect.ValueOf still run, and the value is still walked, for every key.
+var reflectHashers sync.Map // map[reflect.Type]func(interface{}) uint64
+
+// reflectHash hashes a key whose dynamic type isn't one hashKey's switch
+// recognizes, mirroring what the Go runtime does when hashing a
+// map[interface{}]T key: it combines a hash of the dynamic type with a hash
+// of the value itself, walking struct fields and array elements and hashing
+// pointers by address, so that two equal keys of the same type always hash
+// equally.
+func reflectHash(key interface{}) uint64 {
+	t := reflect.TypeOf(key)
+	if t == nil {
+		return 0
+	}
+	if h, ok := reflectHashers.Load(t); ok {
+		return h.(func(interface{}) uint64)(key)
+	}
+	h := newReflectHasher(t)
+	actual, _ := reflectHashers.LoadOrStore(t, h)
+	return actual.(func(interface{}) uint64)(key)
+}
+
+// newReflectHasher builds the hasher cached for t in reflectHash.
+func newReflectHasher(t reflect.Type) func(interface{}) uint64 {
+	typeHash := memHashString(t.String())
+	return func(key interface{}) uint64 {
+		return typeHash ^ hashReflectValue(reflect.ValueOf(key))
+	}
+}
+
+// hashReflectValue hashes the value v holds, recursing into interfaces,
+// structs and arrays; a pointer is hashed by its address, not by what it
+// points to. It never calls v.Interface(), so it works
+// even when v came from an unexported struct field.
+func hashReflectValue(v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.Bool:
+		return memhashBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return memhashInt64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return memhashUint64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f == 0 {
+			f = 0 // -0 == +0, so hash both as +0
+		}
+		return memhashFloat64(f)
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		re, im := real(c), imag(c)
+		if re == 0 {
+			re = 0
+		}
+		if im == 0 {
+			im = 0
+		}
+		return memhashComplex128(complex(re, im))
+	case reflect.String:
+		return memHashString(v.String())
+	case reflect.Ptr, reflect.UnsafePointer, reflect.Chan:
+		return memhashUintptr(v.Pointer())
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return hashReflectValue(v.Elem())
+	case reflect.Array:
+		var h uint64
+		for i := 0; i < v.Len(); i++ {
+			h = h*31 + hashReflectValue(v.Index(i))
+		}
+		return h
+	case reflect.Struct:
+		var h uint64
+		for i := 0; i < v.NumField(); i++ {
+			h = h*31 + hashReflectValue(v.Field(i))
+		}
+		return h
+	default:
+		// Slices, maps and funcs are not comparable, and the Go runtime
+		// panics when such a value is used as a map key; mirror that
+		// rather than hashing it.
+		panic("shardmap: key type " + v.Type().String() + " is not hashable")
+	}
+}
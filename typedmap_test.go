@@ -0,0 +1,142 @@
+package shardmap_test
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/hnakamur/shardmap"
+)
+
+func TestTypedMapStoreLoad(t *testing.T) {
+	m := shardmap.NewTypedMap[string, int](0, shardmap.HasherForString())
+	for i := 0; i < 1000; i++ {
+		m.Store(strconv.Itoa(i), i)
+	}
+	for i := 0; i < 1000; i++ {
+		v, ok := m.Load(strconv.Itoa(i))
+		if !ok || v != i {
+			t.Fatalf("key %d: got (%v, %v), want (%v, true)", i, v, ok, i)
+		}
+	}
+	if _, ok := m.Load("missing"); ok {
+		t.Fatalf("Load of missing key returned ok=true")
+	}
+}
+
+func TestTypedMapLoadOrStore(t *testing.T) {
+	m := shardmap.NewTypedMap[int, string](0, shardmap.HasherForInt())
+	actual, loaded := m.LoadOrStore(1, "a")
+	if loaded || actual != "a" {
+		t.Fatalf("first LoadOrStore: got (%v, %v), want (a, false)", actual, loaded)
+	}
+	actual, loaded = m.LoadOrStore(1, "b")
+	if !loaded || actual != "a" {
+		t.Fatalf("second LoadOrStore: got (%v, %v), want (a, true)", actual, loaded)
+	}
+}
+
+func TestTypedMapDelete(t *testing.T) {
+	m := shardmap.NewTypedMap[int, int](0, shardmap.HasherForInt())
+	m.Store(1, 1)
+	m.Delete(1)
+	if _, ok := m.Load(1); ok {
+		t.Fatalf("Load after Delete returned ok=true")
+	}
+}
+
+func TestTypedMapRange(t *testing.T) {
+	m := shardmap.NewTypedMap[int, int](0, shardmap.HasherForInt())
+	want := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		m.Store(i, i*i)
+		want[i] = i * i
+	}
+	got := make(map[int]int)
+	m.Range(func(key, value int) bool {
+		got[key] = value
+		return true
+	})
+	if len(got) != len(want) {
+		t.Fatalf("Range visited %d keys, want %d", len(got), len(want))
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("key %d: got %d, want %d", k, got[k], v)
+		}
+	}
+}
+
+func TestTypedMapConcurrent(t *testing.T) {
+	m := shardmap.NewTypedMap[int, int](0, shardmap.HasherForInt())
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := g*1000 + i
+				m.Store(key, key)
+				if v, ok := m.Load(key); !ok || v != key {
+					t.Errorf("key %d: got (%v, %v)", key, v, ok)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func benchmarkTypedMapStoreLoad(b *testing.B, n int) {
+	m := shardmap.NewTypedMap[int, int](0, shardmap.HasherForInt())
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := i % n
+			m.Store(key, key)
+			m.Load(key)
+			i++
+		}
+	})
+}
+
+func BenchmarkTypedMapStoreLoad(b *testing.B) {
+	benchmarkTypedMapStoreLoad(b, 1<<16)
+}
+
+func benchmarkMapStoreLoad(b *testing.B, n int) {
+	var m shardmap.Map
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := fmt.Sprintf("%d", i%n)
+			m.Store(key, i)
+			m.Load(key)
+			i++
+		}
+	})
+}
+
+func BenchmarkMapStoreLoad(b *testing.B) {
+	benchmarkMapStoreLoad(b, 1<<16)
+}
+
+func benchmarkSyncMapStoreLoad(b *testing.B, n int) {
+	var m sync.Map
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := fmt.Sprintf("%d", i%n)
+			m.Store(key, i)
+			m.Load(key)
+			i++
+		}
+	})
+}
+
+func BenchmarkSyncMapStoreLoad(b *testing.B) {
+	benchmarkSyncMapStoreLoad(b, 1<<16)
+}